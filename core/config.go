@@ -377,7 +377,7 @@ func (c *Config) SetResolver(name string, fn refunc) error {
 	if _, ok := c.rtmap[name]; ok {
 		return fmt.Errorf("resolver defined: %s", name)
 	}
-	c.rtmap[name] = fn
+	c.rtmap[name] = traceResolverFunc(name, fn)
 	return nil
 }
 
@@ -394,19 +394,50 @@ func ReadInConfigFS(configFile string, fs afero.Fs) (*Config, error) {
 
 func readInConfig(configFile string, fs afero.Fs) (*Config, error) {
 	cp := filepath.Dir(configFile)
-	vi := newViper(cp, filepath.Base(configFile))
 
-	if fs != nil {
-		vi.SetFs(fs)
+	vi, err := mergeInheritedConfig(cp, filepath.Base(configFile), fs)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := vi.ReadInConfig(); err != nil {
-		return nil, err
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, "GJ_") || strings.HasPrefix(e, "SJ_") {
+			kv := strings.SplitN(e, "=", 2)
+			util.SetKeyValue(vi, kv[0], kv[1])
+		}
+	}
+
+	c := &Config{
+		ConfigPath: filepath.Dir(vi.ConfigFileUsed()),
 	}
 
-	if pcf := vi.GetString("inherits"); pcf != "" {
-		cf := vi.ConfigFileUsed()
-		vi = newViper(cp, pcf)
+	if err := vi.Unmarshal(&c); err != nil {
+		return nil, fmt.Errorf("failed to decode config, %v", err)
+	}
+
+	return c, nil
+}
+
+// mergeInheritedConfig walks the 'inherits' chain starting at configFile,
+// loading each layer with its own viper and merging them together so that
+// values from files closer to the leaf take precedence over their
+// ancestors (eg. dev.yml inheriting stage.yml inheriting common.yml). A
+// cycle in the chain is detected with a visited set and returned as an
+// error listing the full cycle path.
+func mergeInheritedConfig(cp, configFile string, fs afero.Fs) (*viper.Viper, error) {
+	var chain []string
+	seen := make(map[string]struct{})
+	cf := configFile
+
+	for {
+		if _, ok := seen[cf]; ok {
+			chain = append(chain, cf)
+			return nil, fmt.Errorf("config inheritance cycle detected: %s", strings.Join(chain, " -> "))
+		}
+		seen[cf] = struct{}{}
+		chain = append(chain, cf)
+
+		vi := newViper(cp, cf)
 		if fs != nil {
 			vi.SetFs(fs)
 		}
@@ -415,38 +446,44 @@ func readInConfig(configFile string, fs afero.Fs) (*Config, error) {
 			return nil, err
 		}
 
-		if v := vi.GetString("inherits"); v != "" {
-			return nil, fmt.Errorf("inherited config '%s' cannot itself inherit '%s'", pcf, v)
-		}
-
-		vi.SetConfigFile(cf)
-
-		if err := vi.MergeInConfig(); err != nil {
-			return nil, err
+		pcf := vi.GetString("inherits")
+		if pcf == "" {
+			break
 		}
+		cf = pcf
 	}
 
-	for _, e := range os.Environ() {
-		if strings.HasPrefix(e, "GJ_") || strings.HasPrefix(e, "SJ_") {
-			kv := strings.SplitN(e, "=", 2)
-			util.SetKeyValue(vi, kv[0], kv[1])
-		}
+	// chain is ordered leaf-to-root. Read the root first, then merge each
+	// subsequent (more specific) layer on top of it.
+	vi := viper.New()
+	if fs != nil {
+		vi.SetFs(fs)
 	}
 
-	c := &Config{
-		ConfigPath: filepath.Dir(vi.ConfigFileUsed()),
-	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		setViperConfigFile(vi, cp, chain[i])
 
-	if err := vi.Unmarshal(&c); err != nil {
-		return nil, fmt.Errorf("failed to decode config, %v", err)
+		var err error
+		if i == len(chain)-1 {
+			err = vi.ReadInConfig()
+		} else {
+			err = vi.MergeInConfig()
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return c, nil
+	return vi, nil
 }
 
 func newViper(configPath, configFile string) *viper.Viper {
 	vi := viper.New()
+	setViperConfigFile(vi, configPath, configFile)
+	return vi
+}
 
+func setViperConfigFile(vi *viper.Viper, configPath, configFile string) {
 	if filepath.Ext(configFile) != "" {
 		vi.SetConfigFile(filepath.Join(configPath, configFile))
 	} else {
@@ -454,8 +491,6 @@ func newViper(configPath, configFile string) *viper.Viper {
 		vi.AddConfigPath(configPath)
 		vi.AddConfigPath("./config")
 	}
-
-	return vi
 }
 
 func GetConfigName() string {
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is used to instrument resolver calls. It's a no-op until serv (or
+// any other caller) installs a real tracer provider with
+// otel.SetTracerProvider, at which point every span created through it
+// attaches to that provider's traces.
+var tracer = otel.Tracer("github.com/dosco/graphjin/core")
+
+// tracingResolver wraps a Resolver so every Resolve call runs inside its own
+// span, carrying the span context alongside the ResolverReq that's passed
+// to the wrapped resolver.
+type tracingResolver struct {
+	name string
+	r    Resolver
+}
+
+func (tr tracingResolver) Resolve(ctx context.Context, req ResolverReq) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "core.resolver.resolve", trace.WithAttributes(
+		attribute.String("resolver.name", tr.name),
+		attribute.String("resolver.id", req.ID),
+	))
+	defer span.End()
+
+	b, err := tr.r.Resolve(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return b, err
+}
+
+// traceResolverFunc wraps a resolver builder function so that every
+// Resolver it produces is automatically instrumented, without resolver
+// authors having to add tracing themselves.
+func traceResolverFunc(name string, fn refunc) refunc {
+	return func(v ResolverProps) (Resolver, error) {
+		r, err := fn(v)
+		if err != nil {
+			return nil, err
+		}
+		return tracingResolver{name: name, r: r}, nil
+	}
+}
@@ -0,0 +1,27 @@
+package serv
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Auth is the configuration for a single named authentication provider.
+// Providers are configured under 'auths' and selected per action via
+// Action.AuthName.
+type Auth struct {
+	Name string
+	Type string
+
+	Rails RailsConfig `mapstructure:"rails" jsonschema:"title=Rails Cookie Auth"`
+}
+
+// authHandler builds the http.Handler for a named auth provider, dispatching
+// on its configured type.
+func (s *service) authHandler(ac Auth, next http.Handler) (http.Handler, error) {
+	switch ac.Type {
+	case "rails":
+		return RailsHandler(ac, next)
+	default:
+		return nil, fmt.Errorf("unknown auth type: %s, for auth: %s", ac.Type, ac.Name)
+	}
+}
@@ -0,0 +1,255 @@
+package serv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1" //nolint: gosec
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	railsPBKDF2Iterations = 1000
+	railsKeyLen           = 32
+)
+
+// RailsConfig is the configuration for authenticating requests off a Rails
+// session cookie. It understands both the Rails 5.2+ AES-256-GCM encrypted
+// cookie format and the older Rails 4 signed (HMAC-SHA1) JSON cookie, as
+// well as session stores (Redis or Memcache) where the cookie only holds an
+// opaque session id.
+type RailsConfig struct {
+	Cookie        string `mapstructure:"cookie" jsonschema:"title=Cookie Name"`
+	SecretKeyBase string `mapstructure:"secret_key_base" jsonschema:"title=Secret Key Base"`
+	Salt          string `mapstructure:"salt" jsonschema:"title=Encryption Key Salt"`
+	SignSalt      string `mapstructure:"sign_salt" jsonschema:"title=Signing Key Salt"`
+	AuthSalt      string `mapstructure:"auth_salt" jsonschema:"title=Auth Key Salt"`
+	Version       string `mapstructure:"version" jsonschema:"title=Rails Version,default=5.2"`
+	UserIDKey     string `mapstructure:"user_id_key" jsonschema:"title=Session User ID Key,default=user_id"`
+
+	// Only used when the cookie holds an opaque session id that must be
+	// looked up in a shared Redis or Memcache session store
+	URL       string `mapstructure:"url" jsonschema:"title=Session Store URL"`
+	Password  string `mapstructure:"password" jsonschema:"title=Session Store Password"`
+	MaxIdle   int    `mapstructure:"max_idle" jsonschema:"title=Max Idle Connections,default=5"`
+	MaxActive int    `mapstructure:"max_active" jsonschema:"title=Max Active Connections,default=20"`
+}
+
+// railsSessionStore fetches the serialized session value for an opaque
+// Rails session id from a shared session store.
+type railsSessionStore interface {
+	Get(id string) ([]byte, error)
+}
+
+// RailsHandler authenticates requests using a Rails session cookie. On
+// success the user id found in the decrypted session is set as the
+// GraphJin user id for the request, otherwise the request is passed
+// through unauthenticated so downstream role checks can block it.
+func RailsHandler(ac Auth, next http.Handler) (http.Handler, error) {
+	rc := ac.Rails
+
+	if rc.Cookie == "" {
+		return nil, fmt.Errorf("auth '%s': rails: 'cookie' is required", ac.Name)
+	}
+
+	if rc.SecretKeyBase == "" {
+		return nil, fmt.Errorf("auth '%s': rails: 'secret_key_base' is required", ac.Name)
+	}
+
+	if rc.UserIDKey == "" {
+		rc.UserIDKey = "user_id"
+	}
+
+	var store railsSessionStore
+
+	if rc.URL != "" {
+		var err error
+		if store, err = newRailsSessionStore(rc); err != nil {
+			return nil, fmt.Errorf("auth '%s': %w", ac.Name, err)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ck, err := r.Cookie(rc.Cookie)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var payload []byte
+
+		if store != nil {
+			payload, err = store.Get(ck.Value)
+		} else {
+			payload, err = railsDecryptSession(rc, ck.Value)
+		}
+
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		uid, err := railsSessionUserID(rc, payload)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(setContextUserID(r.Context(), uid)))
+	}), nil
+}
+
+// railsDecryptSession decrypts (or verifies) a Rails session cookie value
+// and returns the JSON-marshaled session payload.
+func railsDecryptSession(rc RailsConfig, value string) ([]byte, error) {
+	value, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, fmt.Errorf("rails: invalid cookie encoding: %w", err)
+	}
+
+	parts := strings.Split(value, "--")
+
+	switch len(parts) {
+	case 3:
+		// Rails 5.2+ AES-256-GCM: data--iv--auth_tag
+		data, iv, tag, err := railsDecodeParts(parts)
+		if err != nil {
+			return nil, err
+		}
+		key := railsDeriveKey(rc.SecretKeyBase, rc.Salt)
+		return railsDecryptGCM(key, iv[0], append(data[0], tag[0]...))
+
+	case 2:
+		if strings.EqualFold(rc.Version, "4") || strings.EqualFold(rc.Version, "signed") {
+			// Rails 4 signed-only cookie: base64(json)--hexdigest. The
+			// MessageVerifier signs the base64-encoded string itself, not
+			// the decoded payload, so the HMAC must run over parts[0] as-is.
+			digest, err := hex.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("rails: invalid cookie digest: %w", err)
+			}
+			signKey := railsDeriveKey(rc.SecretKeyBase, rc.SignSalt)
+			if err := railsVerifyHMAC(signKey, []byte(parts[0]), digest); err != nil {
+				return nil, err
+			}
+			data, err := base64.StdEncoding.DecodeString(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("rails: invalid cookie data: %w", err)
+			}
+			return data, nil
+		}
+
+		// Older AES-256-CBC encrypted cookie: data--iv
+		data, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("rails: invalid cookie data: %w", err)
+		}
+		iv, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("rails: invalid cookie iv: %w", err)
+		}
+		key := railsDeriveKey(rc.SecretKeyBase, rc.Salt)
+		return railsDecryptCBC(key, iv, data)
+
+	default:
+		return nil, fmt.Errorf("rails: unexpected cookie format with %d segments", len(parts))
+	}
+}
+
+func railsDecodeParts(parts []string) (data, iv, tag [][]byte, err error) {
+	d, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("rails: invalid cookie data: %w", err)
+	}
+	i, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("rails: invalid cookie iv: %w", err)
+	}
+	t, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("rails: invalid cookie auth tag: %w", err)
+	}
+	return [][]byte{d}, [][]byte{i}, [][]byte{t}, nil
+}
+
+// railsDeriveKey derives a 32-byte key from secret_key_base the same way
+// ActiveSupport::KeyGenerator does: PBKDF2-HMAC-SHA1 with 1000 iterations.
+func railsDeriveKey(secretKeyBase, salt string) []byte {
+	return pbkdf2.Key([]byte(secretKeyBase), []byte(salt), railsPBKDF2Iterations, railsKeyLen, sha1.New)
+}
+
+func railsDecryptGCM(key, nonce, ciphertextAndTag []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("rails: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("rails: %w", err)
+	}
+
+	plain, err := gcm.Open(nil, nonce, ciphertextAndTag, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rails: cookie decryption failed: %w", err)
+	}
+
+	return plain, nil
+}
+
+func railsDecryptCBC(key, iv, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("rails: %w", err)
+	}
+
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("rails: invalid cookie ciphertext length")
+	}
+
+	out := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, ciphertext)
+
+	padLen := int(out[len(out)-1])
+	if padLen <= 0 || padLen > aes.BlockSize || padLen > len(out) {
+		return nil, fmt.Errorf("rails: invalid cookie padding")
+	}
+
+	return out[:len(out)-padLen], nil
+}
+
+func railsVerifyHMAC(key, data, digest []byte) error {
+	mac := hmac.New(sha1.New, key)
+	mac.Write(data) //nolint: errcheck
+
+	if !hmac.Equal(mac.Sum(nil), digest) {
+		return fmt.Errorf("rails: cookie signature mismatch")
+	}
+
+	return nil
+}
+
+// railsSessionUserID extracts the configured user id key from the decrypted,
+// JSON-marshaled Rails session payload.
+func railsSessionUserID(rc RailsConfig, payload []byte) (string, error) {
+	var sess map[string]interface{}
+
+	if err := json.Unmarshal(payload, &sess); err != nil {
+		return "", fmt.Errorf("rails: invalid session payload: %w", err)
+	}
+
+	v, ok := sess[rc.UserIDKey]
+	if !ok {
+		return "", fmt.Errorf("rails: session key '%s' not found", rc.UserIDKey)
+	}
+
+	return fmt.Sprintf("%v", v), nil
+}
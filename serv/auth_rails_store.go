@@ -0,0 +1,90 @@
+package serv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/gomodule/redigo/redis"
+)
+
+// newRailsSessionStore builds the session store backing a Rails auth config
+// that points at a shared Redis or Memcache session store rather than
+// shipping the session data inside the cookie itself. The scheme of
+// rc.URL ("redis://" or "memcache://") selects the backend.
+func newRailsSessionStore(rc RailsConfig) (railsSessionStore, error) {
+	switch {
+	case strings.HasPrefix(rc.URL, "redis://"), strings.HasPrefix(rc.URL, "rediss://"):
+		return newRailsRedisStore(rc), nil
+
+	case strings.HasPrefix(rc.URL, "memcache://"):
+		return newRailsMemcacheStore(rc), nil
+
+	default:
+		return nil, fmt.Errorf("rails: unsupported session store url: %s", rc.URL)
+	}
+}
+
+type railsRedisStore struct {
+	pool *redis.Pool
+}
+
+func newRailsRedisStore(rc RailsConfig) *railsRedisStore {
+	maxIdle := rc.MaxIdle
+	if maxIdle == 0 {
+		maxIdle = 5
+	}
+
+	maxActive := rc.MaxActive
+	if maxActive == 0 {
+		maxActive = 20
+	}
+
+	return &railsRedisStore{
+		pool: &redis.Pool{
+			MaxIdle:     maxIdle,
+			MaxActive:   maxActive,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redis.Conn, error) {
+				opts := []redis.DialOption{}
+				if rc.Password != "" {
+					opts = append(opts, redis.DialPassword(rc.Password))
+				}
+				return redis.DialURL(rc.URL, opts...)
+			},
+		},
+	}
+}
+
+// Get fetches the Rails session hash stored under the "session:<id>" key,
+// the default key format used by Rails' ActionDispatch::Session::RedisStore.
+func (s *railsRedisStore) Get(id string) ([]byte, error) {
+	conn := s.pool.Get()
+	defer conn.Close() //nolint: errcheck
+
+	v, err := redis.Bytes(conn.Do("GET", "session:"+id))
+	if err != nil {
+		return nil, fmt.Errorf("rails: redis session lookup failed: %w", err)
+	}
+
+	return v, nil
+}
+
+type railsMemcacheStore struct {
+	client *memcache.Client
+}
+
+func newRailsMemcacheStore(rc RailsConfig) *railsMemcacheStore {
+	addr := strings.TrimPrefix(rc.URL, "memcache://")
+	return &railsMemcacheStore{client: memcache.New(addr)}
+}
+
+func (s *railsMemcacheStore) Get(id string) ([]byte, error) {
+	item, err := s.client.Get("session:" + id)
+	if err != nil {
+		return nil, fmt.Errorf("rails: memcache session lookup failed: %w", err)
+	}
+
+	return item.Value, nil
+}
@@ -0,0 +1,108 @@
+package serv
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressResponseWriter wraps a http.ResponseWriter so that writes go
+// through a gzip or brotli compressor instead of straight to the client. It
+// forwards Flush and Hijack so streamed responses (SSE, websocket upgrades
+// used by GraphJin subscriptions) keep working through the compressor.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	// The downstream handler computed Content-Length for the uncompressed
+	// body; once we compress it that length is wrong, so drop it and let
+	// the server chunk the response instead.
+	cw.ResponseWriter.Header().Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	cw.ResponseWriter.Header().Del("Content-Length")
+	return cw.w.Write(b)
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		f.Flush() //nolint: errcheck
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("compress: underlying ResponseWriter does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+// compressMiddleware negotiates gzip or brotli response compression based on
+// the client's Accept-Encoding header when http_compress is enabled,
+// preferring brotli when the client accepts it.
+func (s *service) compressMiddleware(next http.Handler) http.Handler {
+	if !s.conf.Serv.HTTPCompress {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ae := r.Header.Get("Accept-Encoding")
+
+		switch {
+		case acceptsEncoding(ae, "br"):
+			bw := brotli.NewWriter(w)
+			defer bw.Close() //nolint: errcheck
+
+			w.Header().Set("Content-Encoding", "br")
+			w.Header().Add("Vary", "Accept-Encoding")
+			next.ServeHTTP(&compressResponseWriter{w, bw}, r)
+
+		case acceptsEncoding(ae, "gzip"):
+			gw := gzip.NewWriter(w)
+			defer gw.Close() //nolint: errcheck
+
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Add("Vary", "Accept-Encoding")
+			next.ServeHTTP(&compressResponseWriter{w, gw}, r)
+
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// acceptsEncoding reports whether the client's Accept-Encoding header lists
+// enc with a non-zero q value (eg. "br;q=0" means explicitly refused, not
+// accepted, unlike a plain substring match).
+func acceptsEncoding(ae, enc string) bool {
+	for _, tok := range strings.Split(ae, ",") {
+		name, qs, _ := strings.Cut(strings.TrimSpace(tok), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), enc) {
+			continue
+		}
+
+		qs = strings.TrimSpace(qs)
+		if qs == "" {
+			return true
+		}
+
+		q, err := strconv.ParseFloat(strings.TrimPrefix(qs, "q="), 64)
+		return err != nil || q > 0
+	}
+	return false
+}
@@ -130,6 +130,11 @@ func (s *service) initConfig() error {
 	}
 
 	c.Core.Production = c.Serv.Production
+
+	if err := s.validateSeedAndMigrations(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
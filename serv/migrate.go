@@ -0,0 +1,317 @@
+package serv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const schemaVersionTable = "schema_version"
+
+// placeholder returns the positional bind parameter for arg position n
+// (1-indexed) in the dialect GraphJin is configured for: mysql uses a bare
+// '?' for every argument while postgres uses numbered '$1', '$2', ...
+func (s *service) placeholder(n int) string {
+	if s.conf.Core.DBType == "mysql" {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+// migrationFile is a single timestamp-prefixed SQL migration, eg.
+// 20210415120000_create_users.sql. Each file may contain both an "up" and
+// a "down" section separated by a "-- down" marker line.
+type migrationFile struct {
+	version int64
+	name    string
+	path    string
+}
+
+// Migrate applies or rolls back migrations found under migrations_path.
+// direction is "up" or "down". steps limits how many migrations are run in
+// that direction, 0 means all pending (or, for down, all applied) ones.
+// Migrations are tracked in the schema_version table and work against both
+// postgres and mysql.
+func (s *service) Migrate(ctx context.Context, direction string, steps int) error {
+	if s.conf.DB.MigrationsPath == "" {
+		return fmt.Errorf("migrations_path not set in config")
+	}
+
+	if direction != "up" && direction != "down" {
+		return fmt.Errorf("unknown migration direction: %s", direction)
+	}
+
+	if err := s.initDB(); err != nil {
+		return err
+	}
+
+	if err := s.ensureSchemaVersionTable(ctx); err != nil {
+		return err
+	}
+
+	files, err := s.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if direction == "up" {
+		return s.migrateUp(ctx, files, applied, steps)
+	}
+	return s.migrateDown(ctx, files, applied, steps)
+}
+
+// validateSeedAndMigrations makes sure that, when configured, seed_file and
+// migrations_path point at something that actually exists so a typo is
+// caught at startup instead of the first time 'migrate' or 'seed' runs.
+func (s *service) validateSeedAndMigrations() error {
+	if s.conf.DB.MigrationsPath == "" && s.conf.DB.SeedFile == "" {
+		return nil
+	}
+
+	if err := s.initFS(); err != nil {
+		return err
+	}
+
+	if p := s.conf.DB.MigrationsPath; p != "" {
+		if fi, err := fs.Stat(s.fs, p); err != nil || !fi.IsDir() {
+			return fmt.Errorf("migrations_path '%s' not found", p)
+		}
+	}
+
+	if p := s.conf.DB.SeedFile; p != "" {
+		if _, err := fs.Stat(s.fs, p); err != nil {
+			return fmt.Errorf("seed_file '%s' not found", p)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) migrationFiles() ([]migrationFile, error) {
+	entries, err := fs.ReadDir(s.fs, s.conf.DB.MigrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+
+	files := make([]migrationFile, 0, len(entries))
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".sql" {
+			continue
+		}
+
+		ts, name, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), "_")
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, migrationFile{
+			version: v,
+			name:    name,
+			path:    filepath.Join(s.conf.DB.MigrationsPath, e.Name()),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+	return files, nil
+}
+
+func (s *service) ensureSchemaVersionTable(ctx context.Context) error {
+	ctx, span := s.dbSpan(ctx, "db.migrations.ensure_schema_version_table")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name TEXT NOT NULL)`,
+		schemaVersionTable))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("migrations: %w", err)
+	}
+	return nil
+}
+
+func (s *service) appliedMigrations(ctx context.Context) (map[int64]struct{}, error) {
+	ctx, span := s.dbSpan(ctx, "db.migrations.applied")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT version FROM %s`, schemaVersionTable))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+	defer rows.Close() //nolint: errcheck
+
+	applied := make(map[int64]struct{})
+
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("migrations: %w", err)
+		}
+		applied[v] = struct{}{}
+	}
+
+	return applied, rows.Err()
+}
+
+func (s *service) migrateUp(ctx context.Context, files []migrationFile, applied map[int64]struct{}, steps int) error {
+	ran := 0
+
+	for _, f := range files {
+		if _, ok := applied[f.version]; ok {
+			continue
+		}
+		if steps > 0 && ran == steps {
+			break
+		}
+
+		sqlUp, _, err := s.readMigrationSQL(f)
+		if err != nil {
+			return err
+		}
+
+		if err := s.runMigrationTx(ctx, sqlUp, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(
+				`INSERT INTO %s (version, name) VALUES (%s, %s)`,
+				schemaVersionTable, s.placeholder(1), s.placeholder(2)), f.version, f.name)
+			return err
+		}); err != nil {
+			return fmt.Errorf("migrations: up %d_%s: %w", f.version, f.name, err)
+		}
+
+		s.log.Info("migrated up: %d_%s", f.version, f.name)
+		ran++
+	}
+
+	return nil
+}
+
+func (s *service) migrateDown(ctx context.Context, files []migrationFile, applied map[int64]struct{}, steps int) error {
+	ran := 0
+
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		if _, ok := applied[f.version]; !ok {
+			continue
+		}
+		if steps > 0 && ran == steps {
+			break
+		}
+
+		_, sqlDown, err := s.readMigrationSQL(f)
+		if err != nil {
+			return err
+		}
+
+		if err := s.runMigrationTx(ctx, sqlDown, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(
+				`DELETE FROM %s WHERE version = %s`, schemaVersionTable, s.placeholder(1)), f.version)
+			return err
+		}); err != nil {
+			return fmt.Errorf("migrations: down %d_%s: %w", f.version, f.name, err)
+		}
+
+		s.log.Info("migrated down: %d_%s", f.version, f.name)
+		ran++
+	}
+
+	return nil
+}
+
+// readMigrationSQL splits a migration file on a "-- down" marker line into
+// its up and down statements.
+func (s *service) readMigrationSQL(f migrationFile) (up, down string, err error) {
+	b, err := fs.ReadFile(s.fs, f.path)
+	if err != nil {
+		return "", "", fmt.Errorf("migrations: %w", err)
+	}
+
+	up, down, _ = strings.Cut(string(b), "-- down")
+	return strings.TrimSpace(up), strings.TrimSpace(down), nil
+}
+
+// runMigrationTx runs a migration's SQL, one statement at a time, followed
+// by recordVersion, all inside a single transaction. Statements are split
+// out rather than sent as one query because the mysql driver only executes
+// one statement per query unless the DSN opts into multiStatements, which
+// GraphJin does not require operators to turn on.
+func (s *service) runMigrationTx(ctx context.Context, query string, recordVersion func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range splitSQLStatements(query) {
+		ctx, span := s.dbSpan(ctx, "db.migrations.exec")
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+
+		if err != nil {
+			tx.Rollback() //nolint: errcheck
+			return err
+		}
+	}
+
+	if err := recordVersion(tx); err != nil {
+		tx.Rollback() //nolint: errcheck
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// splitSQLStatements splits a migration's SQL text into the individual
+// statements between its ';' terminators, ignoring semicolons inside single
+// or double quoted strings. Empty statements (blank lines, trailing
+// whitespace after the last ';') are dropped.
+func splitSQLStatements(sqlText string) []string {
+	var stmts []string
+	var b strings.Builder
+
+	var inSingle, inDouble bool
+
+	for i := 0; i < len(sqlText); i++ {
+		c := sqlText[i]
+
+		switch {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		}
+
+		if c == ';' && !inSingle && !inDouble {
+			if stmt := strings.TrimSpace(b.String()); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			b.Reset()
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	if stmt := strings.TrimSpace(b.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts
+}
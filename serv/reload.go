@@ -0,0 +1,160 @@
+package serv
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dosco/graphjin/core"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce coalesces bursts of filesystem events (eg. editors that
+// write a file several times per save) into a single reload.
+const reloadDebounce = 500 * time.Millisecond
+
+// reloadDrainGrace is how long a superseded liveInstance is kept open after
+// being swapped out, so requests that grabbed it just before the swap have
+// time to finish before its DB pool and GraphJin are shut down.
+const reloadDrainGrace = 5 * time.Second
+
+// liveInstance is the GraphJin engine and DB pool currently serving
+// requests. It's swapped atomically by reload() so in-flight requests keep
+// running against the instance they started with; the superseded instance
+// is then shut down by closeLiveInstance.
+type liveInstance struct {
+	gj *core.GraphJin
+	db *sql.DB
+}
+
+// startReloadWatcher starts an fsnotify watcher over the config directory
+// and script path when reload_on_config_change is enabled. On any change it
+// rebuilds the config, GraphJin instance and DB pool in the background and
+// atomically swaps them into place. This is a filesystem-driven dev
+// workflow, distinct from the HotDeploy admin endpoint which is triggered
+// explicitly rather than by file changes.
+func (s *service) startReloadWatcher(ctx context.Context) error {
+	if !s.conf.Serv.WatchAndReload {
+		return nil
+	}
+
+	bp, err := s.basePath()
+	if err != nil {
+		return err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{bp, s.conf.Core.ScriptPath} {
+		if dir == "" {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			s.log.Warn("reload watcher: unable to watch '%s': %s", dir, err)
+		}
+	}
+
+	go s.reloadLoop(ctx, w)
+	return nil
+}
+
+func (s *service) reloadLoop(ctx context.Context, w *fsnotify.Watcher) {
+	defer w.Close() //nolint: errcheck
+
+	var t *time.Timer
+
+	for {
+		select {
+		case <-ctx.Done():
+			if t != nil {
+				t.Stop()
+			}
+			return
+
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+			if t != nil {
+				t.Stop()
+			}
+			t = time.AfterFunc(reloadDebounce, s.reload)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			s.log.Warn("reload watcher: %s", err)
+		}
+	}
+}
+
+// reload re-reads the config (resolving its full inherits chain) and
+// allow-list from disk, builds a fresh GraphJin instance and DB pool, and
+// swaps them into the live service. On any error the previous, still
+// running instance is left untouched and the error is logged.
+func (s *service) reload() {
+	conf, err := ReadInConfig(s.cpath)
+	if err != nil {
+		s.log.Error("config reload: %s", err)
+		return
+	}
+
+	ns := &service{conf: conf, log: s.log, fs: s.fs, cpath: s.cpath}
+
+	if err := ns.initConfig(); err != nil {
+		s.log.Error("config reload: %s", err)
+		return
+	}
+
+	if err := ns.initDB(); err != nil {
+		s.log.Error("config reload: %s", err)
+		return
+	}
+
+	if err := ns.initGraphJin(); err != nil {
+		s.log.Error("config reload: %s", err)
+		return
+	}
+
+	old, _ := s.live.Load().(*liveInstance)
+
+	s.live.Store(&liveInstance{gj: ns.gj, db: ns.db})
+	s.log.Info("config reloaded")
+
+	if old != nil {
+		go s.closeLiveInstance(old)
+	}
+}
+
+// closeLiveInstance shuts down a liveInstance that's just been superseded
+// by a reload. It waits out reloadDrainGrace first so requests that
+// grabbed it via liveGraphJin() just before the swap have time to finish,
+// then stops its GraphJin's background pollers and closes its DB pool -
+// otherwise every reload leaks both.
+func (s *service) closeLiveInstance(li *liveInstance) {
+	time.Sleep(reloadDrainGrace)
+
+	if err := li.gj.Close(); err != nil {
+		s.log.Warn("config reload: closing previous instance: %s", err)
+	}
+
+	if err := li.db.Close(); err != nil {
+		s.log.Warn("config reload: closing previous db: %s", err)
+	}
+}
+
+// liveGraphJin returns the GraphJin instance currently serving requests,
+// falling back to the one built at startup when reloading is disabled.
+func (s *service) liveGraphJin() *core.GraphJin {
+	if v, ok := s.live.Load().(*liveInstance); ok {
+		return v.gj
+	}
+	return s.gj
+}
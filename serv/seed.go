@@ -0,0 +1,138 @@
+package serv
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/dosco/graphjin/core"
+	"github.com/dosco/graphjin/plugin/js"
+)
+
+// Seed runs the JavaScript seed file (seed_file) through the scripting
+// plugin, exposing the same graphql() and import_csv() helpers available to
+// custom resolvers so fixtures are written through GraphJin's own mutation
+// pipeline and therefore respect role rules and insert/update presets.
+func (s *service) Seed(ctx context.Context) error {
+	if s.conf.DB.SeedFile == "" {
+		return fmt.Errorf("seed_file not set in config")
+	}
+
+	if err := s.initDB(); err != nil {
+		return err
+	}
+
+	if err := s.initGraphJin(); err != nil {
+		return err
+	}
+
+	b, err := fs.ReadFile(s.fs, s.conf.DB.SeedFile)
+	if err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+
+	return s.runSeedScript(ctx, string(b))
+}
+
+// runSeedScript executes the seed script body in the scripting VM used for
+// resolvers, wiring up the graphql() and import_csv() helpers against the
+// live GraphJin instance.
+func (s *service) runSeedScript(ctx context.Context, script string) error {
+	gj := s.liveGraphJin()
+
+	vm := js.New(s.conf.DB.SeedFile, script)
+
+	vm.Set("graphql", func(query string, vars map[string]interface{}, opt map[string]interface{}) (interface{}, error) {
+		role, _ := opt["role"].(string)
+
+		vj, err := json.Marshal(vars)
+		if err != nil {
+			return nil, fmt.Errorf("seed: %w", err)
+		}
+
+		res, err := gj.GraphQL(ctx, query, json.RawMessage(vj), &core.ReqConfig{Role: role})
+		if err != nil {
+			return nil, err
+		}
+		return res.Data, nil
+	})
+
+	vm.Set("import_csv", func(table, file string) error {
+		return s.importCSV(ctx, gj, table, file)
+	})
+
+	if err := vm.Run(); err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+
+	return nil
+}
+
+// importCSV loads a CSV file (relative to the seed file's directory) into
+// table via a GraphJin bulk insert mutation, reusing the same insert
+// pipeline (and therefore the same presets and role checks) as a normal API
+// request.
+func (s *service) importCSV(ctx context.Context, gj *core.GraphJin, table, file string) error {
+	p := filepath.Join(filepath.Dir(s.conf.DB.SeedFile), file)
+
+	f, err := s.fs.Open(p)
+	if err != nil {
+		return fmt.Errorf("import_csv: %w", err)
+	}
+	defer f.Close() //nolint: errcheck
+
+	rows, err := parseCSV(f)
+	if err != nil {
+		return fmt.Errorf("import_csv: %w", err)
+	}
+
+	vj, err := json.Marshal(map[string]interface{}{"input": rows})
+	if err != nil {
+		return fmt.Errorf("import_csv: %w", err)
+	}
+
+	query := fmt.Sprintf(`mutation { %s(insert: $input) { id } }`, table)
+	_, err = gj.GraphQL(ctx, query, json.RawMessage(vj), &core.ReqConfig{Role: "admin"})
+	if err != nil {
+		return fmt.Errorf("import_csv: %w", err)
+	}
+
+	return nil
+}
+
+// parseCSV reads a CSV file using its first row as column names, returning
+// one map per data row suitable for use as GraphQL mutation input.
+func parseCSV(f fs.File) ([]map[string]interface{}, error) {
+	r := csv.NewReader(f)
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]interface{}
+
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(header))
+		for i, col := range header {
+			if i < len(rec) {
+				row[col] = rec[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}